@@ -0,0 +1,159 @@
+// Command loki-migrate-schema wires chunk.SchemaConfig.Load() and chunk.Migrator.Run behind
+// flags for the migration window, checkpoint key and dry-run toggle.
+//
+// This tree has no index store, object store or series-store client code to build a real
+// chunk.IndexScanner, chunk.IndexWriter, chunk.ObjectClient, chunk.TenantLister or
+// chunk.EntryRehasher against - those are deployment-specific (DynamoDB, Bigtable, Cassandra,
+// ...) and none of that client code exists here. newScanner, newWriter, newObjectClient,
+// newTenants and newRehasher below are the seams a real deployment fills in; as shipped they
+// return a clear error instead of either compiling against packages that don't exist or
+// silently no-op'ing.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+type stdoutReporter struct{}
+
+func (stdoutReporter) ReportShard(userID string, bucket, shard, count int) {
+	fmt.Printf("tenant=%s bucket=%d shard=%d entries=%d\n", userID, bucket, shard, count)
+}
+
+func main() {
+	var (
+		schemaConfig chunk.SchemaConfig
+		srcFrom      string
+		dstFrom      string
+		from         string
+		through      string
+		checkpoint   string
+		dryRun       bool
+	)
+
+	schemaConfig.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&srcFrom, "src", "", "`from` date (YYYY-MM-DD) of the source PeriodConfig in the schema config file")
+	flag.StringVar(&dstFrom, "dst", "", "`from` date (YYYY-MM-DD) of the destination PeriodConfig in the schema config file")
+	flag.StringVar(&from, "window.from", "", "start of the window to migrate (YYYY-MM-DD)")
+	flag.StringVar(&through, "window.through", "", "end of the window to migrate (YYYY-MM-DD)")
+	flag.StringVar(&checkpoint, "checkpoint-key", "loki-migrate-schema/checkpoint.json", "object store key under which migration progress is checkpointed")
+	flag.BoolVar(&dryRun, "dry-run", false, "count entries that would be migrated for each tenant/bucket without writing them")
+	flag.Parse()
+
+	if err := schemaConfig.Load(); err != nil {
+		exitf("loading schema config: %v", err)
+	}
+
+	src, err := periodFrom(schemaConfig, srcFrom)
+	if err != nil {
+		exitf("resolving -src: %v", err)
+	}
+	dst, err := periodFrom(schemaConfig, dstFrom)
+	if err != nil {
+		exitf("resolving -dst: %v", err)
+	}
+
+	window, err := parseWindow(from, through)
+	if err != nil {
+		exitf("parsing window: %v", err)
+	}
+
+	scanner, err := newScanner(src)
+	if err != nil {
+		exitf("building index scanner: %v", err)
+	}
+	writer, err := newWriter(dst)
+	if err != nil {
+		exitf("building index writer: %v", err)
+	}
+	objectClient, err := newObjectClient(src)
+	if err != nil {
+		exitf("building object client: %v", err)
+	}
+	tenants, err := newTenants(objectClient)
+	if err != nil {
+		exitf("building tenant lister: %v", err)
+	}
+
+	migrator := &chunk.Migrator{
+		Scanner:     scanner,
+		Writer:      writer,
+		Tenants:     tenants,
+		Checkpoints: chunk.NewObjectStoreCheckpoints(objectClient, checkpoint),
+		Rehash:      newRehasher(),
+		Reporter:    stdoutReporter{},
+		DryRun:      dryRun,
+	}
+
+	if err := migrator.Run(context.Background(), src, dst, window); err != nil {
+		exitf("migration failed: %v", err)
+	}
+}
+
+// periodFrom returns the PeriodConfig in cfg whose From matches fromDate (YYYY-MM-DD).
+func periodFrom(cfg chunk.SchemaConfig, fromDate string) (chunk.PeriodConfig, error) {
+	for _, p := range cfg.Configs {
+		if p.From.String() == fromDate {
+			return p, nil
+		}
+	}
+	return chunk.PeriodConfig{}, fmt.Errorf("no period config with from=%s", fromDate)
+}
+
+func parseWindow(from, through string) (model.Interval, error) {
+	fromTime, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return model.Interval{}, fmt.Errorf("parsing -window.from: %w", err)
+	}
+	throughTime, err := time.Parse("2006-01-02", through)
+	if err != nil {
+		return model.Interval{}, fmt.Errorf("parsing -window.through: %w", err)
+	}
+	return model.Interval{
+		Start: model.TimeFromUnix(fromTime.Unix()),
+		End:   model.TimeFromUnix(throughTime.Unix()),
+	}, nil
+}
+
+// newScanner must return a chunk.IndexScanner backed by cfg.IndexType's real index client.
+func newScanner(cfg chunk.PeriodConfig) (chunk.IndexScanner, error) {
+	return nil, fmt.Errorf("no chunk.IndexScanner implementation wired up for index store %q - plug one in here", cfg.IndexType)
+}
+
+// newWriter must return a chunk.IndexWriter backed by cfg.IndexType's real index client.
+func newWriter(cfg chunk.PeriodConfig) (chunk.IndexWriter, error) {
+	return nil, fmt.Errorf("no chunk.IndexWriter implementation wired up for index store %q - plug one in here", cfg.IndexType)
+}
+
+// newObjectClient must return a chunk.ObjectClient backed by cfg.ObjectType's real object
+// store client; it backs both the tenant lister and the checkpoint store above.
+func newObjectClient(cfg chunk.PeriodConfig) (chunk.ObjectClient, error) {
+	return nil, fmt.Errorf("no chunk.ObjectClient implementation wired up for object store %q - plug one in here", cfg.ObjectType)
+}
+
+// newTenants must return a chunk.TenantLister that enumerates every tenant with data in client.
+func newTenants(client chunk.ObjectClient) (chunk.TenantLister, error) {
+	return nil, fmt.Errorf("no chunk.TenantLister implementation wired up - plug one in here")
+}
+
+// newRehasher must return a chunk.EntryRehasher that knows how to decompose an IndexEntry
+// scanned from the source schema back into the series/label info the destination BaseSchema
+// needs to re-derive hash and range values - see chunk.EntryRehasher's doc comment.
+func newRehasher() chunk.EntryRehasher {
+	return func(entry chunk.IndexEntry, dst chunk.PeriodConfig, dstSchema chunk.BaseSchema) ([]chunk.IndexEntry, error) {
+		return nil, fmt.Errorf("no chunk.EntryRehasher implementation wired up for schema %s - plug one in here", dst.Schema)
+	}
+}
+
+func exitf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,124 @@
+package chunk
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRegisterExternalKeyFormat_RoundTrip registers a third-party format - one a built-in
+// schema version never produces - and checks it round-trips through both ExternalKey/
+// ParseExternalKey (which already consulted the registry) and ParseExternalKeyForSchema
+// (which now does too).
+func TestRegisterExternalKeyFormat_RoundTrip(t *testing.T) {
+	t.Cleanup(func() { delete(externalKeyFormats, "test-fs-safe") })
+
+	RegisterExternalKeyFormat("test-fs-safe", 9, 11,
+		func(c Chunk) string {
+			return fmt.Sprintf("%s_%x_%x_%x_%x", c.UserID, uint64(c.Fingerprint), int64(c.From), int64(c.Through), c.Checksum)
+		},
+		func(key string) (Chunk, error) {
+			var userID string
+			var fp, from, through, checksum uint64
+			n, err := fmt.Sscanf(key, "%[^_]_%x_%x_%x_%x", &userID, &fp, &from, &through, &checksum)
+			if err != nil || n != 5 {
+				return Chunk{}, fmt.Errorf("%w: %q: %v", errKeyWrongFormat, key, err)
+			}
+			return Chunk{
+				UserID:      userID,
+				Fingerprint: model.Fingerprint(fp),
+				From:        model.Time(from),
+				Through:     model.Time(through),
+				Checksum:    uint32(checksum),
+				ChecksumSet: true,
+			}, nil
+		},
+	)
+
+	cfg := SchemaConfig{Configs: []PeriodConfig{{
+		From:              DayTime{model.TimeFromUnix(0)},
+		Schema:            "v11",
+		RowShards:         16,
+		IndexTables:       PeriodicTableConfig{Prefix: "index_"},
+		ExternalKeyFormat: "test-fs-safe",
+	}}}
+	require.NoError(t, cfg.Validate())
+
+	chunk := Chunk{
+		UserID:      "fake",
+		Fingerprint: model.Fingerprint(12345),
+		From:        model.TimeFromUnix(100),
+		Through:     model.TimeFromUnix(200),
+		Checksum:    6789,
+		ChecksumSet: true,
+	}
+
+	key := cfg.ExternalKey(chunk)
+	require.Equal(t, "fake_3039_64_c8_1a85", key)
+
+	parsed, err := cfg.ParseExternalKey(key)
+	require.NoError(t, err)
+	require.Equal(t, chunk, parsed)
+
+	parsedForSchema, err := cfg.ParseExternalKeyForSchema(11, key)
+	require.NoError(t, err)
+	require.Equal(t, chunk, parsedForSchema)
+}
+
+func TestPeriodConfig_ExternalKeyFormat_RejectsSchemaOutOfRange(t *testing.T) {
+	cfg := PeriodConfig{
+		From:              DayTime{model.TimeFromUnix(0)},
+		Schema:            v12,
+		RowShards:         16,
+		IndexTables:       PeriodicTableConfig{Prefix: "index_"},
+		ExternalKeyFormat: "legacy", // "legacy" only covers schemas 0-11
+	}
+
+	require.Error(t, cfg.validate())
+}
+
+func TestPeriodConfig_ExternalKeyFormat_RejectsUnknownName(t *testing.T) {
+	cfg := PeriodConfig{
+		From:              DayTime{model.TimeFromUnix(0)},
+		Schema:            v12,
+		RowShards:         16,
+		IndexTables:       PeriodicTableConfig{Prefix: "index_"},
+		ExternalKeyFormat: "does-not-exist",
+	}
+
+	require.Error(t, cfg.validate())
+}
+
+func TestPeriodConfig_ExternalKeyFormat_RejectsOverrideOutOfRange(t *testing.T) {
+	cfg := PeriodConfig{
+		From:              DayTime{model.TimeFromUnix(0)},
+		Schema:            "v11",
+		RowShards:         16,
+		IndexTables:       PeriodicTableConfig{Prefix: "index_"},
+		ExternalKeyFormat: "legacy", // "legacy" only covers schemas 0-11
+		Overrides: map[string]PeriodOverride{
+			"team-a": {Schema: v12},
+		},
+	}
+
+	// team-a's override bumps its effective schema to v12, which "legacy" doesn't cover,
+	// even though the period's own schema (v11) still passes.
+	require.Error(t, cfg.validate())
+}
+
+func TestPeriodConfig_ExternalKeyFormat_AllowsOverrideInRange(t *testing.T) {
+	cfg := PeriodConfig{
+		From:              DayTime{model.TimeFromUnix(0)},
+		Schema:            "v11",
+		RowShards:         16,
+		IndexTables:       PeriodicTableConfig{Prefix: "index_"},
+		ExternalKeyFormat: "legacy",
+		Overrides: map[string]PeriodOverride{
+			"team-a": {RowShards: uint32Ptr(4)},
+		},
+	}
+
+	require.NoError(t, cfg.validate())
+}
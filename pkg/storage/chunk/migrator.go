@@ -0,0 +1,237 @@
+package chunk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// IndexEntry is a single hash/range/value row read from or written to an index table -
+// the same shape BaseSchema implementations produce for writes and consume for reads.
+type IndexEntry struct {
+	TableName  string
+	HashValue  string
+	RangeValue []byte
+	Value      []byte
+}
+
+// IndexScanner reads every IndexEntry belonging to one (userID, bucket, shard) out of a
+// source schema's index, where bucket is an index into src's PeriodConfig.BucketingPeriod-sized
+// buckets (the day number for every schema up to v12, or the period index for v13's
+// configurable BucketPeriod).
+type IndexScanner interface {
+	// ScanShard calls f once per IndexEntry found in tableName for the bucket hashed from
+	// userID, bucket and shard. It returns once the bucket has been scanned fully, ctx is
+	// cancelled, or f returns an error.
+	ScanShard(ctx context.Context, tableName string, userID string, bucket, shard int, f func(IndexEntry) error) error
+}
+
+// IndexWriter writes re-hashed IndexEntry rows into a destination schema's index.
+type IndexWriter interface {
+	WriteEntries(ctx context.Context, entries []IndexEntry) error
+}
+
+// TenantLister enumerates the tenants a Migrator.Run should cover, in any order - Run sorts
+// them itself before iterating, since Checkpoint.before depends on a lexicographic order.
+type TenantLister interface {
+	ListTenants(ctx context.Context) ([]string, error)
+}
+
+// EntryRehasher re-hashes a single IndexEntry scanned from src's index into zero or more
+// IndexEntry rows for dst's index. It's schema-specific - how a raw entry decomposes back
+// into the series/label info BaseSchema needs to re-derive hash and range values depends on
+// which BaseSchema wrote it - so Migrator takes it as a dependency rather than assuming one.
+type EntryRehasher func(entry IndexEntry, dst PeriodConfig, dstSchema BaseSchema) ([]IndexEntry, error)
+
+// Checkpoint identifies the last (userID, bucket, shard) a Migrator.Run fully migrated, so a
+// later call can resume instead of rescanning src's index from the start. bucket is in units
+// of src's PeriodConfig.BucketingPeriod, so it's only comparable across Run calls that migrate
+// the same src.
+type Checkpoint struct {
+	UserID string `json:"user_id"`
+	Bucket int    `json:"bucket"`
+	Shard  int    `json:"shard"`
+}
+
+// before reports whether the (userID, bucket, shard) comes strictly before c in the iteration
+// order Run uses (userID, then bucket, then shard).
+func (c Checkpoint) before(userID string, bucket, shard int) bool {
+	if userID != c.UserID {
+		return userID < c.UserID
+	}
+	if bucket != c.Bucket {
+		return bucket < c.Bucket
+	}
+	return shard < c.Shard
+}
+
+// CheckpointStore persists and retrieves a Migrator.Run's Checkpoint. Implementations are
+// expected to store it in the object store the source and destination schemas share.
+type CheckpointStore interface {
+	// LoadCheckpoint returns the last saved Checkpoint, or nil if none has been saved yet.
+	LoadCheckpoint(ctx context.Context) (*Checkpoint, error)
+	SaveCheckpoint(ctx context.Context, c Checkpoint) error
+}
+
+// ProgressReporter is notified as a Migrator.Run progresses, so callers can log, export
+// metrics, or print dry-run counts without Run taking a dependency on how they do it.
+type ProgressReporter interface {
+	// ReportShard is called once a (userID, bucket, shard) has been fully processed, with
+	// the number of entries migrated for it. In dry-run mode, count is the number of entries
+	// that would have been written.
+	ReportShard(userID string, bucket, shard, count int)
+}
+
+// NopProgressReporter discards all progress reports.
+type NopProgressReporter struct{}
+
+func (NopProgressReporter) ReportShard(string, int, int, int) {}
+
+// Migrator converges a source PeriodConfig's index entries onto a destination PeriodConfig's
+// schema, re-hashing each entry through the destination's BaseSchema and writing it back out
+// without touching the chunk blobs the entries point at - both PeriodConfigs must share the
+// same object store for chunks.
+//
+// Migrator only defines the interfaces above; it has no concrete IndexScanner, IndexWriter or
+// TenantLister of its own, and NewObjectStoreCheckpoints is the only concrete CheckpointStore.
+// A caller wiring up a runnable migration (e.g. a CLI command) needs to supply adapters for
+// whichever index and object clients its deployment actually uses.
+//
+// To dual-write during a migration, install the destination PeriodConfig as of the cutover
+// time with SchemaConfig.ForEachAfter(cutoverTime, ...) so new writes land under the new
+// schema immediately, then call Run with a window ending at cutoverTime to back-fill
+// everything written before it.
+type Migrator struct {
+	Scanner     IndexScanner
+	Writer      IndexWriter
+	Tenants     TenantLister
+	Checkpoints CheckpointStore
+	Rehash      EntryRehasher
+	Reporter    ProgressReporter
+
+	// DryRun, when true, scans and re-hashes entries and reports counts via Reporter, but
+	// never calls Writer.
+	DryRun bool
+}
+
+// Run migrates every entry in window from src's index into dst's index, across every tenant
+// Tenants returns, resuming after the last saved Checkpoint if one is present. It returns once
+// the whole window has been migrated, ctx is cancelled, or a shard fails to migrate.
+func (m *Migrator) Run(ctx context.Context, src, dst PeriodConfig, window model.Interval) error {
+	dstSchema, err := dst.CreateSchema("")
+	if err != nil {
+		return fmt.Errorf("building destination schema: %w", err)
+	}
+
+	if _, err := src.CreateSchema(""); err != nil {
+		return fmt.Errorf("building source schema: %w", err)
+	}
+
+	users, err := m.Tenants.ListTenants(ctx)
+	if err != nil {
+		return fmt.Errorf("listing tenants: %w", err)
+	}
+	// Checkpoint.before assumes users are iterated in lexicographic order - TenantLister makes
+	// no such guarantee, so sort here rather than trust whatever order the caller's
+	// implementation happens to return.
+	sort.Strings(users)
+
+	checkpoint, err := m.Checkpoints.LoadCheckpoint(ctx)
+	if err != nil {
+		return fmt.Errorf("loading checkpoint: %w", err)
+	}
+
+	periodSecs := int64(src.BucketingPeriod() / time.Second)
+	fromBucket := int(window.Start.Unix() / periodSecs)
+	throughBucket := int(window.End.Unix() / periodSecs)
+	rowShards := int(src.RowShards)
+	if rowShards == 0 {
+		rowShards = 1
+	}
+
+	for _, userID := range users {
+		for bucket := fromBucket; bucket <= throughBucket; bucket++ {
+			for shard := 0; shard < rowShards; shard++ {
+				if checkpoint != nil && checkpoint.before(userID, bucket, shard) {
+					continue
+				}
+
+				tableName := src.IndexTables.TableFor(model.TimeFromUnix(int64(bucket) * periodSecs))
+				count := 0
+				err := m.Scanner.ScanShard(ctx, tableName, userID, bucket, shard, func(entry IndexEntry) error {
+					rehashed, err := m.Rehash(entry, dst, dstSchema)
+					if err != nil {
+						return fmt.Errorf("re-hashing entry %+v: %w", entry, err)
+					}
+					count += len(rehashed)
+					if m.DryRun || len(rehashed) == 0 {
+						return nil
+					}
+					return m.Writer.WriteEntries(ctx, rehashed)
+				})
+				if err != nil {
+					return fmt.Errorf("migrating tenant %s bucket %d shard %d: %w", userID, bucket, shard, err)
+				}
+
+				m.Reporter.ReportShard(userID, bucket, shard, count)
+
+				checkpoint = &Checkpoint{UserID: userID, Bucket: bucket, Shard: shard}
+				if err := m.Checkpoints.SaveCheckpoint(ctx, *checkpoint); err != nil {
+					return fmt.Errorf("saving checkpoint after tenant %s bucket %d shard %d: %w", userID, bucket, shard, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ObjectClient is the subset of the object storage client the default CheckpointStore needs.
+type ObjectClient interface {
+	PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error
+	GetObject(ctx context.Context, objectKey string) (io.ReadCloser, error)
+	IsObjectNotFoundErr(err error) bool
+}
+
+// objectStoreCheckpoints stores a Migrator.Run's Checkpoint as a small JSON object at key in
+// client, which is expected to be the same object store both schemas keep chunks in.
+type objectStoreCheckpoints struct {
+	client ObjectClient
+	key    string
+}
+
+// NewObjectStoreCheckpoints returns a CheckpointStore that persists its Checkpoint as a JSON
+// object at key in client.
+func NewObjectStoreCheckpoints(client ObjectClient, key string) CheckpointStore {
+	return &objectStoreCheckpoints{client: client, key: key}
+}
+
+func (c *objectStoreCheckpoints) LoadCheckpoint(ctx context.Context) (*Checkpoint, error) {
+	r, err := c.client.GetObject(ctx, c.key)
+	if err != nil {
+		if c.client.IsObjectNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	var checkpoint Checkpoint
+	if err := json.NewDecoder(r).Decode(&checkpoint); err != nil {
+		return nil, fmt.Errorf("decoding checkpoint %q: %w", c.key, err)
+	}
+	return &checkpoint, nil
+}
+
+func (c *objectStoreCheckpoints) SaveCheckpoint(ctx context.Context, checkpoint Checkpoint) error {
+	buf, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+	return c.client.PutObject(ctx, c.key, bytes.NewReader(buf))
+}
@@ -0,0 +1,220 @@
+package chunk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeScanKey identifies one (tableName, userID, bucket, shard) shard a fakeScanner can serve.
+type fakeScanKey struct {
+	tableName string
+	userID    string
+	bucket    int
+	shard     int
+}
+
+// fakeScanner is an IndexScanner backed by an in-memory map of shard -> entries, and records
+// every shard it was actually asked to scan so tests can assert on which ones Run visited.
+type fakeScanner struct {
+	entries map[fakeScanKey][]IndexEntry
+	scanned []fakeScanKey
+}
+
+func (f *fakeScanner) ScanShard(_ context.Context, tableName, userID string, bucket, shard int, cb func(IndexEntry) error) error {
+	key := fakeScanKey{tableName, userID, bucket, shard}
+	f.scanned = append(f.scanned, key)
+	for _, e := range f.entries[key] {
+		if err := cb(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fakeWriter is an IndexWriter that records every entry it's asked to write.
+type fakeWriter struct {
+	written []IndexEntry
+}
+
+func (f *fakeWriter) WriteEntries(_ context.Context, entries []IndexEntry) error {
+	f.written = append(f.written, entries...)
+	return nil
+}
+
+type fakeTenants []string
+
+func (f fakeTenants) ListTenants(context.Context) ([]string, error) { return f, nil }
+
+// fakeCheckpoints is a CheckpointStore backed by an in-memory pointer, seeded with an optional
+// starting Checkpoint to simulate resuming a prior Migrator.Run.
+type fakeCheckpoints struct {
+	checkpoint *Checkpoint
+	saved      []Checkpoint
+}
+
+func (f *fakeCheckpoints) LoadCheckpoint(context.Context) (*Checkpoint, error) {
+	return f.checkpoint, nil
+}
+
+func (f *fakeCheckpoints) SaveCheckpoint(_ context.Context, c Checkpoint) error {
+	f.saved = append(f.saved, c)
+	f.checkpoint = &c
+	return nil
+}
+
+// fakeReporter is a ProgressReporter that records every ReportShard call.
+type fakeReporter struct {
+	reports []struct {
+		userID               string
+		bucket, shard, count int
+	}
+}
+
+func (f *fakeReporter) ReportShard(userID string, bucket, shard, count int) {
+	f.reports = append(f.reports, struct {
+		userID               string
+		bucket, shard, count int
+	}{userID, bucket, shard, count})
+}
+
+// identityRehash passes every entry through unchanged, as if src and dst hashed identically.
+func identityRehash(entry IndexEntry, _ PeriodConfig, _ BaseSchema) ([]IndexEntry, error) {
+	return []IndexEntry{entry}, nil
+}
+
+func migratorTestConfigs(rowShards uint32) (src, dst PeriodConfig) {
+	cfg := PeriodConfig{
+		From:        DayTime{model.TimeFromUnix(0)},
+		Schema:      v12,
+		RowShards:   rowShards,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+	}
+	return cfg, cfg
+}
+
+func TestMigrator_Run_ResumesAroundCheckpointBoundary(t *testing.T) {
+	src, dst := migratorTestConfigs(2)
+
+	shard0 := fakeScanKey{tableName: "index_", userID: "fake", bucket: 0, shard: 0}
+	shard1 := fakeScanKey{tableName: "index_", userID: "fake", bucket: 0, shard: 1}
+	scanner := &fakeScanner{entries: map[fakeScanKey][]IndexEntry{
+		shard0: {{TableName: "index_", HashValue: "fake:d0:s0"}},
+		shard1: {{TableName: "index_", HashValue: "fake:d0:s1"}},
+	}}
+	writer := &fakeWriter{}
+	// A prior Run already fully migrated shard 1, so this one should skip shard 0 (it comes
+	// before the checkpoint) and replay shard 1 (the checkpoint's own boundary).
+	checkpoints := &fakeCheckpoints{checkpoint: &Checkpoint{UserID: "fake", Bucket: 0, Shard: 1}}
+
+	m := Migrator{
+		Scanner:     scanner,
+		Writer:      writer,
+		Tenants:     fakeTenants{"fake"},
+		Checkpoints: checkpoints,
+		Rehash:      identityRehash,
+		Reporter:    NopProgressReporter{},
+	}
+
+	window := model.Interval{Start: model.TimeFromUnix(0), End: model.TimeFromUnix(0)}
+	require.NoError(t, m.Run(context.Background(), src, dst, window))
+
+	require.Equal(t, []fakeScanKey{shard1}, scanner.scanned)
+	require.Equal(t, []IndexEntry{{TableName: "index_", HashValue: "fake:d0:s1"}}, writer.written)
+}
+
+func TestMigrator_Run_SortsTenantsRegardlessOfListTenantsOrder(t *testing.T) {
+	src, dst := migratorTestConfigs(1)
+
+	key := fakeScanKey{tableName: "index_", userID: "team-b", bucket: 0, shard: 0}
+	scanner := &fakeScanner{entries: map[fakeScanKey][]IndexEntry{
+		key: {{TableName: "index_", HashValue: "team-b:d0"}},
+	}}
+	checkpoints := &fakeCheckpoints{}
+
+	m := Migrator{
+		Scanner: scanner,
+		Writer:  &fakeWriter{},
+		// ListTenants returns "team-b" before "team-a" - if Run trusted that order instead of
+		// sorting, it would save a checkpoint for team-b first and then, on a later resume,
+		// wrongly treat team-a (which sorts before team-b) as already covered.
+		Tenants:     fakeTenants{"team-b", "team-a"},
+		Checkpoints: checkpoints,
+		Rehash:      identityRehash,
+		Reporter:    NopProgressReporter{},
+	}
+
+	window := model.Interval{Start: model.TimeFromUnix(0), End: model.TimeFromUnix(0)}
+	require.NoError(t, m.Run(context.Background(), src, dst, window))
+
+	require.Equal(t, []fakeScanKey{
+		{tableName: "index_", userID: "team-a", bucket: 0, shard: 0},
+		key,
+	}, scanner.scanned)
+}
+
+func TestMigrator_Run_DryRunNeverWrites(t *testing.T) {
+	src, dst := migratorTestConfigs(1)
+
+	key := fakeScanKey{tableName: "index_", userID: "fake", bucket: 0, shard: 0}
+	scanner := &fakeScanner{entries: map[fakeScanKey][]IndexEntry{
+		key: {{TableName: "index_", HashValue: "fake:d0"}},
+	}}
+	writer := &fakeWriter{}
+	reporter := &fakeReporter{}
+
+	m := Migrator{
+		Scanner:     scanner,
+		Writer:      writer,
+		Tenants:     fakeTenants{"fake"},
+		Checkpoints: &fakeCheckpoints{},
+		Rehash:      identityRehash,
+		Reporter:    reporter,
+		DryRun:      true,
+	}
+
+	window := model.Interval{Start: model.TimeFromUnix(0), End: model.TimeFromUnix(0)}
+	require.NoError(t, m.Run(context.Background(), src, dst, window))
+
+	require.Empty(t, writer.written)
+	require.Len(t, reporter.reports, 1)
+	require.Equal(t, 1, reporter.reports[0].count)
+}
+
+func TestMigrator_Run_ReportedCountMatchesRehashedEntries(t *testing.T) {
+	src, dst := migratorTestConfigs(1)
+
+	key := fakeScanKey{tableName: "index_", userID: "fake", bucket: 0, shard: 0}
+	scanner := &fakeScanner{entries: map[fakeScanKey][]IndexEntry{
+		key: {
+			{TableName: "index_", HashValue: "fake:d0", RangeValue: []byte("a")},
+			{TableName: "index_", HashValue: "fake:d0", RangeValue: []byte("b")},
+		},
+	}}
+	writer := &fakeWriter{}
+	reporter := &fakeReporter{}
+
+	// rehash fans each scanned entry out into two written entries, so the reported count
+	// should reflect the rehashed total, not the number of entries scanned.
+	fanOutRehash := func(entry IndexEntry, _ PeriodConfig, _ BaseSchema) ([]IndexEntry, error) {
+		return []IndexEntry{entry, entry}, nil
+	}
+
+	m := Migrator{
+		Scanner:     scanner,
+		Writer:      writer,
+		Tenants:     fakeTenants{"fake"},
+		Checkpoints: &fakeCheckpoints{},
+		Rehash:      fanOutRehash,
+		Reporter:    reporter,
+	}
+
+	window := model.Interval{Start: model.TimeFromUnix(0), End: model.TimeFromUnix(0)}
+	require.NoError(t, m.Run(context.Background(), src, dst, window))
+
+	require.Len(t, writer.written, 4)
+	require.Len(t, reporter.reports, 1)
+	require.Equal(t, 4, reporter.reports[0].count)
+}
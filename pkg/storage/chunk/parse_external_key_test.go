@@ -0,0 +1,106 @@
+package chunk
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func schemaConfigFor(t *testing.T, schema string, rowShards uint32) SchemaConfig {
+	t.Helper()
+	cfg := SchemaConfig{Configs: []PeriodConfig{{
+		From:        DayTime{model.TimeFromUnix(0)},
+		Schema:      schema,
+		RowShards:   rowShards,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+	}}}
+	require.NoError(t, cfg.Validate())
+	return cfg
+}
+
+func TestParseExternalKey_RoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		schema    string
+		rowShards uint32
+		chunk     Chunk
+	}{
+		{
+			name:   "legacy (pre-checksum, v9)",
+			schema: "v9",
+			chunk: Chunk{
+				Fingerprint: model.Fingerprint(12345),
+				From:        model.TimeFromUnix(100),
+				Through:     model.TimeFromUnix(200),
+			},
+		},
+		{
+			name:      "new (post-checksum, v11)",
+			schema:    "v11",
+			rowShards: 16,
+			chunk: Chunk{
+				UserID:      "fake",
+				Fingerprint: model.Fingerprint(12345),
+				From:        model.TimeFromUnix(100),
+				Through:     model.TimeFromUnix(200),
+				Checksum:    12345,
+				ChecksumSet: true,
+			},
+		},
+		{
+			name:      "newer (v12+)",
+			schema:    v12,
+			rowShards: 16,
+			chunk: Chunk{
+				UserID:      "fake",
+				Fingerprint: model.Fingerprint(12345),
+				From:        model.TimeFromUnix(100),
+				Through:     model.TimeFromUnix(200),
+				Checksum:    12345,
+				ChecksumSet: true,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := schemaConfigFor(t, tc.schema, tc.rowShards)
+
+			key := cfg.ExternalKey(tc.chunk)
+			parsed, err := cfg.ParseExternalKey(key)
+			require.NoError(t, err)
+			require.Equal(t, tc.chunk, parsed)
+		})
+	}
+}
+
+func TestParseExternalKey_WrongFormat(t *testing.T) {
+	cfg := schemaConfigFor(t, v12, 16)
+
+	_, err := cfg.ParseExternalKey("not-a-valid-key")
+	require.ErrorIs(t, err, errKeyWrongFormat)
+}
+
+func TestParseExternalKey_Corrupt(t *testing.T) {
+	cfg := schemaConfigFor(t, v12, 16)
+
+	_, err := cfg.ParseExternalKey("fake/zzzz:64:c8:3039")
+	require.ErrorIs(t, err, errKeyCorrupt)
+}
+
+func TestParseExternalKeyForSchema_RejectsWrongShapeForVersion(t *testing.T) {
+	cfg := schemaConfigFor(t, v12, 16)
+
+	newerKey := newerExternalKey(Chunk{
+		UserID:      "fake",
+		Fingerprint: model.Fingerprint(1),
+		From:        model.TimeFromUnix(0),
+		Through:     model.TimeFromUnix(60),
+		Checksum:    1,
+		ChecksumSet: true,
+	})
+
+	// A v12+ key parsed as if it belonged to a pre-v12 schema has the wrong shape for every
+	// format registered in that range.
+	_, err := cfg.ParseExternalKeyForSchema(11, newerKey)
+	require.ErrorIs(t, err, errKeyWrongFormat)
+}
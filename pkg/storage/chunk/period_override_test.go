@@ -0,0 +1,197 @@
+package chunk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/mtime"
+)
+
+func uint32Ptr(v uint32) *uint32 { return &v }
+
+func TestPeriodConfig_Overrides_NoMatchIsUnchanged(t *testing.T) {
+	cfg := PeriodConfig{
+		From:        DayTime{model.TimeFromUnix(0)},
+		Schema:      v12,
+		RowShards:   16,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+		Overrides: map[string]PeriodOverride{
+			"team-*": {RowShards: uint32Ptr(4)},
+		},
+	}
+
+	buckets := cfg.dailyBuckets(model.TimeFromUnix(0), model.TimeFromUnix(3600), "unrelated-tenant")
+	require.Len(t, buckets, 1)
+	require.Equal(t, "unrelated-tenant:d0", buckets[0].hashKey)
+}
+
+func TestPeriodConfig_Overrides_SchemaChangeGetsNewSchemasRowShardsDefault(t *testing.T) {
+	// v9's RowShards default is 0 (it doesn't shard); an override bumping schema to v10 with
+	// no RowShards of its own must still pick up v10's own default rather than keeping v9's.
+	cfg := PeriodConfig{
+		From:   DayTime{model.TimeFromUnix(0)},
+		Schema: "v9",
+		Overrides: map[string]PeriodOverride{
+			"team-a": {Schema: "v10"},
+		},
+	}
+	cfg.applyDefaults()
+	require.NoError(t, cfg.validate())
+
+	schema, err := cfg.CreateSchema("team-a")
+	require.NoError(t, err)
+	require.NotNil(t, schema)
+}
+
+func TestPeriodConfig_Overrides_RowShardsSuffixesHashKey(t *testing.T) {
+	cfg := PeriodConfig{
+		From:        DayTime{model.TimeFromUnix(0)},
+		Schema:      v12,
+		RowShards:   16,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+		Overrides: map[string]PeriodOverride{
+			"team-*": {RowShards: uint32Ptr(4)},
+		},
+	}
+
+	buckets := cfg.dailyBuckets(model.TimeFromUnix(0), model.TimeFromUnix(3600), "team-a")
+	require.Len(t, buckets, 1)
+	require.Equal(t, "team-a:d0:s4", buckets[0].hashKey)
+}
+
+func TestPeriodConfig_Overrides_RegexTenantMatch(t *testing.T) {
+	cfg := PeriodConfig{
+		Overrides: map[string]PeriodOverride{
+			"~^team-\\d+$": {RowShards: uint32Ptr(4)},
+		},
+	}
+
+	ov, ok := cfg.overrideFor("team-123")
+	require.True(t, ok)
+	require.Equal(t, uint32(4), *ov.RowShards)
+
+	_, ok = cfg.overrideFor("team-abc")
+	require.False(t, ok)
+}
+
+func TestPeriodConfig_overrideFor_SortedKeyPrecedence(t *testing.T) {
+	cfg := PeriodConfig{
+		Overrides: map[string]PeriodOverride{
+			"team-*": {RowShards: uint32Ptr(4)},
+			"team-a": {RowShards: uint32Ptr(8)},
+		},
+	}
+
+	// "team-*" sorts before "team-a" lexicographically ('*' < 'a'), so it wins
+	// deterministically for a tenant both patterns match.
+	ov, ok := cfg.overrideFor("team-a")
+	require.True(t, ok)
+	require.Equal(t, uint32(4), *ov.RowShards)
+}
+
+func TestSchemaConfig_ForEachAfter_PreservesOverrides(t *testing.T) {
+	cfg := SchemaConfig{Configs: []PeriodConfig{{
+		From:      DayTime{model.TimeFromUnix(0)},
+		Schema:    v12,
+		RowShards: 16,
+		Overrides: map[string]PeriodOverride{
+			"team-a": {RowShards: uint32Ptr(4)},
+		},
+	}}}
+
+	splitAt := model.TimeFromUnix(3600)
+	var seen []*PeriodConfig
+	cfg.ForEachAfter(splitAt, func(p *PeriodConfig) { seen = append(seen, p) })
+
+	require.Len(t, cfg.Configs, 2)
+	require.Equal(t, cfg.Configs[0].Overrides, cfg.Configs[1].Overrides)
+	require.Len(t, seen, 1)
+	require.Equal(t, splitAt, seen[0].From.Time)
+}
+
+func TestSchemaConfig_Validate_RejectsSchemaOverrideOnActivePeriod(t *testing.T) {
+	mtime.NowForce(time.Unix(1000000, 0))
+	defer mtime.NowReset()
+
+	cfg := SchemaConfig{Configs: []PeriodConfig{{
+		From:        DayTime{model.TimeFromUnix(0)},
+		Schema:      v12,
+		RowShards:   16,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+		Overrides: map[string]PeriodOverride{
+			"team-a": {Schema: "v11"},
+		},
+	}}}
+
+	require.Error(t, cfg.Validate())
+}
+
+func TestSchemaConfig_Validate_RejectsRowShardsOverrideOnActivePeriod(t *testing.T) {
+	mtime.NowForce(time.Unix(1000000, 0))
+	defer mtime.NowReset()
+
+	cfg := SchemaConfig{Configs: []PeriodConfig{{
+		From:        DayTime{model.TimeFromUnix(0)},
+		Schema:      v12,
+		RowShards:   16,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+		Overrides: map[string]PeriodOverride{
+			"team-a": {RowShards: uint32Ptr(4)},
+		},
+	}}}
+
+	require.Error(t, cfg.Validate())
+}
+
+func TestSchemaConfig_Validate_RejectsIndexPrefixOverrideOnActivePeriod(t *testing.T) {
+	mtime.NowForce(time.Unix(1000000, 0))
+	defer mtime.NowReset()
+
+	cfg := SchemaConfig{Configs: []PeriodConfig{{
+		From:        DayTime{model.TimeFromUnix(0)},
+		Schema:      v12,
+		RowShards:   16,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+		Overrides: map[string]PeriodOverride{
+			"team-a": {IndexPrefix: "index2_"},
+		},
+	}}}
+
+	require.Error(t, cfg.Validate())
+}
+
+func TestSchemaConfig_Validate_AllowsOverrideMatchingExistingValuesOnActivePeriod(t *testing.T) {
+	mtime.NowForce(time.Unix(1000000, 0))
+	defer mtime.NowReset()
+
+	cfg := SchemaConfig{Configs: []PeriodConfig{{
+		From:        DayTime{model.TimeFromUnix(0)},
+		Schema:      v12,
+		RowShards:   16,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+		Overrides: map[string]PeriodOverride{
+			"team-a": {Schema: v12, RowShards: uint32Ptr(16), IndexPrefix: "index_"},
+		},
+	}}}
+
+	require.NoError(t, cfg.Validate())
+}
+
+func TestSchemaConfig_Validate_AllowsOverrideOnFuturePeriod(t *testing.T) {
+	mtime.NowForce(time.Unix(1000000, 0))
+	defer mtime.NowReset()
+
+	cfg := SchemaConfig{Configs: []PeriodConfig{{
+		From:        DayTime{model.TimeFromUnix(2000000)},
+		Schema:      v12,
+		RowShards:   16,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+		Overrides: map[string]PeriodOverride{
+			"team-a": {RowShards: uint32Ptr(4)},
+		},
+	}}}
+
+	require.NoError(t, cfg.Validate())
+}
@@ -5,6 +5,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -22,8 +25,20 @@ const (
 	secondsInDay      = int64(24 * time.Hour / time.Second)
 	millisecondsInDay = int64(24 * time.Hour / time.Millisecond)
 	v12               = "v12"
+	v13               = "v13"
+
+	defaultBucketPeriod = 24 * time.Hour
 )
 
+// validBucketPeriods are the only BucketPeriod values a v13 PeriodConfig may set.
+var validBucketPeriods = map[time.Duration]bool{
+	time.Hour:       true,
+	6 * time.Hour:   true,
+	12 * time.Hour:  true,
+	24 * time.Hour:  true,
+	168 * time.Hour: true,
+}
+
 var (
 	errInvalidSchemaVersion     = errors.New("invalid schema version")
 	errInvalidTablePeriod       = errors.New("the table period must be a multiple of 24h (1h for schema v1)")
@@ -42,10 +57,37 @@ type PeriodConfig struct {
 	ChunkTables PeriodicTableConfig `yaml:"chunks"`
 	RowShards   uint32              `yaml:"row_shards"`
 
+	// BucketPeriod sets how much time each index bucket covers, for schemas that support a
+	// configurable bucket size (v13+); one of 1h, 6h, 12h, 24h or 168h. Ignored by schemas
+	// older than v13, which always use a 24h bucket. Defaults to 24h if unset.
+	BucketPeriod model.Duration `yaml:"bucket_period,omitempty"`
+
+	// Overrides lets specific tenants, matched by glob or regex pattern, use a different
+	// RowShards, Schema or IndexTables.Prefix than the rest of this period.
+	Overrides map[string]PeriodOverride `yaml:"overrides,omitempty"`
+
+	// ExternalKeyFormat pins this period to a chunk external-key format registered with
+	// RegisterExternalKeyFormat, by name, instead of the format ExternalKey would otherwise
+	// pick from cfg.Schema and the chunk's checksum. The named format's schema range must
+	// contain this period's schema version.
+	ExternalKeyFormat string `yaml:"external_key_format,omitempty"`
+
 	// Integer representation of schema used for hot path calculation. Populated on unmarshaling.
 	schemaInt *int `yaml:"-"`
 }
 
+// PeriodOverride pins tenants matching a pattern to a different RowShards, Schema, or
+// IndexTables.Prefix than the rest of the PeriodConfig.Overrides map it's nested in -
+// analogous to how go-carbon's storage-aggregation config selects among several aggregation
+// methods by pattern. It's keyed by a glob (e.g. "team-*") or, prefixed with "~", a regular
+// expression (e.g. "~^team-(a|b)$") that tenant IDs are matched against. Unset fields fall
+// back to the enclosing PeriodConfig's own value.
+type PeriodOverride struct {
+	RowShards   *uint32 `yaml:"row_shards,omitempty"`
+	Schema      string  `yaml:"schema,omitempty"`
+	IndexPrefix string  `yaml:"index_prefix,omitempty"`
+}
+
 // UnmarshalYAML implements yaml.Unmarshaller.
 func (cfg *PeriodConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	type plain PeriodConfig
@@ -119,6 +161,7 @@ func (cfg *SchemaConfig) loadFromFile() error {
 // Validate the schema config and returns an error if the validation
 // doesn't pass
 func (cfg *SchemaConfig) Validate() error {
+	now := mtime.Now()
 	for i := range cfg.Configs {
 		periodCfg := &cfg.Configs[i]
 		periodCfg.applyDefaults()
@@ -126,6 +169,24 @@ func (cfg *SchemaConfig) Validate() error {
 			return err
 		}
 
+		// A period that's already started writing can't have its effective schema, RowShards
+		// or IndexPrefix changed out from under it for any tenant - they all determine how a
+		// chunk already written for that tenant is keyed or where it's stored, so changing any
+		// of them makes previously-written buckets unreadable under the new override.
+		if periodCfg.From.Time.Time().Before(now) {
+			for pattern, ov := range periodCfg.Overrides {
+				if ov.Schema != "" && ov.Schema != periodCfg.Schema {
+					return fmt.Errorf("override %q would change the schema (%s -> %s) of period %s, which has already started being written", pattern, periodCfg.Schema, ov.Schema, periodCfg.From)
+				}
+				if ov.RowShards != nil && *ov.RowShards != periodCfg.RowShards {
+					return fmt.Errorf("override %q would change the row_shards (%d -> %d) of period %s, which has already started being written", pattern, periodCfg.RowShards, *ov.RowShards, periodCfg.From)
+				}
+				if ov.IndexPrefix != "" && ov.IndexPrefix != periodCfg.IndexTables.Prefix {
+					return fmt.Errorf("override %q would change the index table prefix (%s -> %s) of period %s, which has already started being written", pattern, periodCfg.IndexTables.Prefix, ov.IndexPrefix, periodCfg.From)
+				}
+			}
+		}
+
 		if i+1 < len(cfg.Configs) {
 			if cfg.Configs[i].From.Time.Unix() >= cfg.Configs[i+1].From.Time.Unix() {
 				return errSchemaIncreasingFromTime
@@ -176,9 +237,22 @@ func validateChunks(cfg PeriodConfig) error {
 	}
 }
 
-// CreateSchema returns the schema defined by the PeriodConfig
-func (cfg PeriodConfig) CreateSchema() (BaseSchema, error) {
-	buckets, bucketsPeriod := cfg.dailyBuckets, 24*time.Hour
+// CreateSchema returns the schema defined by the PeriodConfig, after applying any
+// PeriodOverride matching tenant on top of it. Pass "" for tenant to get the period's own
+// schema with no override applied; a tenant with no matching override also gets that same
+// schema, so its bucket hash keys are bit-identical to what they were before overrides
+// existed.
+func (cfg PeriodConfig) CreateSchema(tenant string) (BaseSchema, error) {
+	cfg = cfg.withOverrides(tenant)
+
+	buckets, bucketsPeriod := cfg.dailyBuckets, defaultBucketPeriod
+	if cfg.Schema == v13 {
+		bucketsPeriod = cfg.BucketingPeriod()
+		if !validBucketPeriods[bucketsPeriod] {
+			return nil, fmt.Errorf("invalid bucket_period %s for schema (%s): must be one of 1h, 6h, 12h, 24h, 168h", bucketsPeriod, cfg.Schema)
+		}
+		buckets = cfg.bucketsFn(bucketsPeriod)
+	}
 
 	// Ensure the tables period is a multiple of the bucket period
 	if cfg.IndexTables.Period > 0 && cfg.IndexTables.Period%bucketsPeriod != 0 {
@@ -192,24 +266,54 @@ func (cfg PeriodConfig) CreateSchema() (BaseSchema, error) {
 	switch cfg.Schema {
 	case "v9":
 		return newSeriesStoreSchema(buckets, v9Entries{}), nil
-	case "v10", "v11", v12:
+	case "v10", "v11", v12, v13:
 		if cfg.RowShards == 0 {
 			return nil, fmt.Errorf("must have row_shards > 0 (current: %d) for schema (%s)", cfg.RowShards, cfg.Schema)
 		}
 
 		v10 := v10Entries{rowShards: cfg.RowShards}
-		if cfg.Schema == "v10" {
+		switch cfg.Schema {
+		case "v10":
 			return newSeriesStoreSchema(buckets, v10), nil
-		} else if cfg.Schema == "v11" {
+		case "v11":
 			return newSeriesStoreSchema(buckets, v11Entries{v10}), nil
-		} else { // v12
+		case v12:
 			return newSeriesStoreSchema(buckets, v12Entries{v11Entries{v10}}), nil
+		default: // v13
+			return newSeriesStoreSchema(buckets, v13Entries{v12Entries{v11Entries{v10}}}), nil
 		}
 	default:
 		return nil, errInvalidSchemaVersion
 	}
 }
 
+// v13Entries is schema v12's entry formats unchanged - v13 only changes how buckets are sized
+// (a configurable BucketPeriod instead of the hard-coded 24h day), which CreateSchema already
+// accounts for via bucketsFn, so v13Entries needs nothing of its own beyond embedding
+// v12Entries to inherit its GetWriteEntries/GetReadQueries/GetChunkWriteEntries methods.
+type v13Entries struct {
+	v12Entries
+}
+
+// effectiveBucketPeriod returns cfg.BucketPeriod if it's set, or the default of 24h.
+func (cfg PeriodConfig) effectiveBucketPeriod() time.Duration {
+	if cfg.BucketPeriod == 0 {
+		return defaultBucketPeriod
+	}
+	return time.Duration(cfg.BucketPeriod)
+}
+
+// BucketingPeriod returns the time span one of cfg's index buckets covers: cfg.BucketPeriod
+// (or the default of 24h if unset) for schema v13, and the fixed 24h dailyBuckets uses for
+// every earlier schema. Callers that need to iterate cfg's buckets directly, such as
+// Migrator, should derive their granularity from this rather than assuming 24h.
+func (cfg PeriodConfig) BucketingPeriod() time.Duration {
+	if cfg.Schema == v13 {
+		return cfg.effectiveBucketPeriod()
+	}
+	return defaultBucketPeriod
+}
+
 func (cfg *PeriodConfig) applyDefaults() {
 	if cfg.RowShards == 0 {
 		cfg.RowShards = defaultRowShards(cfg.Schema)
@@ -223,8 +327,112 @@ func (cfg PeriodConfig) validate() error {
 		return validateError
 	}
 
-	_, err := cfg.CreateSchema()
-	return err
+	if _, err := cfg.CreateSchema(""); err != nil {
+		return err
+	}
+
+	var externalKeyFormat ExternalKeyFormat
+	if cfg.ExternalKeyFormat != "" {
+		f, ok := externalKeyFormats[cfg.ExternalKeyFormat]
+		if !ok {
+			return fmt.Errorf("unknown external_key_format %q", cfg.ExternalKeyFormat)
+		}
+		externalKeyFormat = f
+		v, err := cfg.VersionAsInt()
+		if err != nil {
+			return err
+		}
+		if !f.inSchemaRange(v) {
+			return fmt.Errorf("external_key_format %q does not support schema %s", cfg.ExternalKeyFormat, cfg.Schema)
+		}
+	}
+
+	for pattern, ov := range cfg.Overrides {
+		overridden := cfg.withOverride(ov)
+		if _, err := overridden.CreateSchema(""); err != nil {
+			return fmt.Errorf("invalid override %q: %w", pattern, err)
+		}
+
+		// A Schema override changes the tenant's effective schema version without touching
+		// ExternalKeyFormat, which isn't itself overridable - so an ExternalKeyFormat pinned
+		// for the period's own schema can fall out of range for an overridden tenant's.
+		if cfg.ExternalKeyFormat != "" {
+			v, err := overridden.VersionAsInt()
+			if err != nil {
+				return fmt.Errorf("invalid override %q: %w", pattern, err)
+			}
+			if !externalKeyFormat.inSchemaRange(v) {
+				return fmt.Errorf("override %q: external_key_format %q does not support schema %s", pattern, cfg.ExternalKeyFormat, overridden.Schema)
+			}
+		}
+	}
+	return nil
+}
+
+// overrideFor returns the PeriodOverride registered for tenant, if any. Overrides are
+// matched in sorted-key order, so a tenant matching more than one pattern deterministically
+// gets the lexicographically smallest matching key.
+func (cfg PeriodConfig) overrideFor(tenant string) (PeriodOverride, bool) {
+	if tenant == "" || len(cfg.Overrides) == 0 {
+		return PeriodOverride{}, false
+	}
+
+	patterns := make([]string, 0, len(cfg.Overrides))
+	for pattern := range cfg.Overrides {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if matchTenant(pattern, tenant) {
+			return cfg.Overrides[pattern], true
+		}
+	}
+	return PeriodOverride{}, false
+}
+
+// matchTenant reports whether tenant matches pattern. A pattern prefixed with "~" is a
+// regular expression (with the prefix stripped); anything else is a shell glob matched with
+// path.Match, e.g. "team-*".
+func matchTenant(pattern, tenant string) bool {
+	if rest := strings.TrimPrefix(pattern, "~"); rest != pattern {
+		re, err := regexp.Compile(rest)
+		return err == nil && re.MatchString(tenant)
+	}
+	ok, err := path.Match(pattern, tenant)
+	return err == nil && ok
+}
+
+// withOverride returns a copy of cfg with ov's set fields applied on top of it.
+func (cfg PeriodConfig) withOverride(ov PeriodOverride) PeriodConfig {
+	if ov.Schema != "" {
+		cfg.Schema = ov.Schema
+		cfg.schemaInt = nil
+	}
+	if ov.RowShards != nil {
+		cfg.RowShards = *ov.RowShards
+	} else if ov.Schema != "" {
+		// A Schema override with no RowShards override of its own inherits the period's
+		// RowShards as-is, which may be wrong for the new schema (e.g. the period's own
+		// schema is v9, where RowShards defaults to 0, but the override's schema needs
+		// RowShards > 0) - apply the new schema's own default the same way the period's
+		// own schema got one.
+		cfg.applyDefaults()
+	}
+	if ov.IndexPrefix != "" {
+		cfg.IndexTables.Prefix = ov.IndexPrefix
+	}
+	return cfg
+}
+
+// withOverrides returns a copy of cfg with the PeriodOverride matching tenant, if any,
+// applied on top of it. A tenant with no matching override gets cfg back unchanged.
+func (cfg PeriodConfig) withOverrides(tenant string) PeriodConfig {
+	ov, ok := cfg.overrideFor(tenant)
+	if !ok {
+		return cfg
+	}
+	return cfg.withOverride(ov)
 }
 
 // Load the yaml file, or build the config from legacy command-line flags
@@ -274,13 +482,67 @@ func (cfg *PeriodConfig) dailyBuckets(from, through model.Time, userID string) [
 			from:       uint32(relativeFrom),
 			through:    uint32(relativeThrough),
 			tableName:  cfg.IndexTables.TableFor(model.TimeFromUnix(i * secondsInDay)),
-			hashKey:    fmt.Sprintf("%s:d%d", userID, i),
+			hashKey:    cfg.bucketHashKey(userID, i),
 			bucketSize: uint32(millisecondsInDay), // helps with deletion of series ids in series store
 		})
 	}
 	return result
 }
 
+// bucketHashKey builds the hash key for the bucket covering index for userID, in the
+// day-bucketed format ("%s:d<day>") dailyBuckets uses. A tenant with a RowShards override gets
+// its shard count folded into the key (":s<shards>") so its buckets never collide with the
+// ones it would have had under the period's own RowShards; a tenant with no override gets
+// exactly the key it would have gotten before overrides existed.
+func (cfg *PeriodConfig) bucketHashKey(userID string, day int64) string {
+	return cfg.shardedHashKey(fmt.Sprintf("%s:d%d", userID, day), userID)
+}
+
+// periodBucketHashKey is like bucketHashKey, but for the period-bucketed format ("%s:p<period
+// in hours>:<bucket index>") bucketsFn uses, so schema v13's RowShards overrides apply exactly
+// as uniformly as dailyBuckets' do.
+func (cfg *PeriodConfig) periodBucketHashKey(userID string, periodHours, index int64) string {
+	return cfg.shardedHashKey(fmt.Sprintf("%s:p%d:%d", userID, periodHours, index), userID)
+}
+
+// shardedHashKey appends userID's RowShards override shard count to key, if one is set.
+func (cfg *PeriodConfig) shardedHashKey(key, userID string) string {
+	if ov, ok := cfg.overrideFor(userID); ok && ov.RowShards != nil {
+		return fmt.Sprintf("%s:s%d", key, *ov.RowShards)
+	}
+	return key
+}
+
+// bucketsFn is like dailyBuckets, but buckets by period instead of a hard-coded day. Its hash
+// key folds period into itself ("%s:p<period in hours>:<bucket index>"), so buckets for one
+// BucketPeriod never collide with another's, or with dailyBuckets' "%s:d<day>" keys.
+func (cfg *PeriodConfig) bucketsFn(period time.Duration) func(from, through model.Time, userID string) []Bucket {
+	periodSecs := int64(period / time.Second)
+	periodMillis := int64(period / time.Millisecond)
+	periodHours := int64(period / time.Hour)
+
+	return func(from, through model.Time, userID string) []Bucket {
+		var (
+			fromBucket    = from.Unix() / periodSecs
+			throughBucket = through.Unix() / periodSecs
+			result        = []Bucket{}
+		)
+
+		for i := fromBucket; i <= throughBucket; i++ {
+			relativeFrom := math.Max64(0, int64(from)-(i*periodMillis))
+			relativeThrough := math.Min64(periodMillis, int64(through)-(i*periodMillis))
+			result = append(result, Bucket{
+				from:       uint32(relativeFrom),
+				through:    uint32(relativeThrough),
+				tableName:  cfg.IndexTables.TableFor(model.TimeFromUnix(i * periodSecs)),
+				hashKey:    cfg.periodBucketHashKey(userID, periodHours, i),
+				bucketSize: uint32(periodMillis),
+			})
+		}
+		return result
+	}
+}
+
 func (cfg *PeriodConfig) VersionAsInt() (int, error) {
 	// Read memoized schema version. This is called during unmarshaling,
 	// but may be nil in the case of testware.
@@ -413,22 +675,24 @@ func (cfg *PeriodicTableConfig) periodicTables(from, through model.Time, pCfg Pr
 	return result
 }
 
-// ChunkTableFor calculates the chunk table shard for a given point in time.
-func (cfg SchemaConfig) ChunkTableFor(t model.Time) (string, error) {
+// ChunkTableFor calculates the chunk table shard for a given point in time and tenant.
+func (cfg SchemaConfig) ChunkTableFor(t model.Time, tenant string) (string, error) {
 	for i := range cfg.Configs {
 		if t >= cfg.Configs[i].From.Time && (i+1 == len(cfg.Configs) || t < cfg.Configs[i+1].From.Time) {
-			return cfg.Configs[i].ChunkTables.TableFor(t), nil
+			return cfg.Configs[i].withOverrides(tenant).ChunkTables.TableFor(t), nil
 		}
 	}
 	return "", fmt.Errorf("no chunk table found for time %v", t)
 }
 
-// SchemaForTime returns the Schema PeriodConfig to use for a given point in time.
-func (cfg SchemaConfig) SchemaForTime(t model.Time) (PeriodConfig, error) {
+// SchemaForTime returns the Schema PeriodConfig to use for a given point in time and tenant,
+// with any PeriodOverride matching tenant applied on top of the period's own config. Pass ""
+// for tenant to get the period's own config with no override applied.
+func (cfg SchemaConfig) SchemaForTime(t model.Time, tenant string) (PeriodConfig, error) {
 	for i := range cfg.Configs {
 		// TODO: callum, confirm we can rely on the schema configs being sorted in this order.
 		if t >= cfg.Configs[i].From.Time && (i+1 == len(cfg.Configs) || t < cfg.Configs[i+1].From.Time) {
-			return cfg.Configs[i], nil
+			return cfg.Configs[i].withOverrides(tenant), nil
 		}
 	}
 	return PeriodConfig{}, fmt.Errorf("no schema config found for time %v", t)
@@ -447,41 +711,286 @@ func (cfg *PeriodicTableConfig) tableForPeriod(i int64) string {
 	return cfg.Prefix + strconv.Itoa(int(i))
 }
 
+// ExternalKeyFormat is a named, schema-version-ranged chunk external-key codec. Format turns
+// a Chunk into its key; Parse is its exact inverse, returning an error wrapping
+// errKeyWrongFormat if key isn't in this format at all, or errKeyCorrupt if it is but has a
+// corrupt field.
+type ExternalKeyFormat struct {
+	Name      string
+	MinSchema int
+	MaxSchema int // 0 means "no upper bound"
+	Format    func(Chunk) string
+	Parse     func(string) (Chunk, error)
+}
+
+// inSchemaRange reports whether schema version v falls within f's [MinSchema, MaxSchema].
+func (f ExternalKeyFormat) inSchemaRange(v int) bool {
+	return v >= f.MinSchema && (f.MaxSchema == 0 || v <= f.MaxSchema)
+}
+
+var externalKeyFormats = map[string]ExternalKeyFormat{}
+
+// RegisterExternalKeyFormat registers a named chunk external-key format for schema versions in
+// [minSchema, maxSchema] (maxSchema == 0 means unbounded). A PeriodConfig can pin itself to the
+// format by name via PeriodConfig.ExternalKeyFormat. This lets third parties - e.g. an object
+// store that needs a filesystem-safe key, or a content-addressable layout - register their own
+// format without patching this file.
+func RegisterExternalKeyFormat(name string, minSchema, maxSchema int, format func(Chunk) string, parse func(string) (Chunk, error)) {
+	externalKeyFormats[name] = ExternalKeyFormat{
+		Name:      name,
+		MinSchema: minSchema,
+		MaxSchema: maxSchema,
+		Format:    format,
+		Parse:     parse,
+	}
+}
+
+func init() {
+	RegisterExternalKeyFormat("legacy", 0, 11, legacyExternalKey, parseKeyAsLegacy)
+	RegisterExternalKeyFormat("new", 0, 11, newExternalKey, parseKeyAsNew)
+	RegisterExternalKeyFormat("newer", 12, 0, newerExternalKey, parseKeyAsNewer)
+}
+
 // Generate the appropriate external key based on cfg.Schema, chunk.Checksum, and chunk.From
 func (cfg SchemaConfig) ExternalKey(chunk Chunk) string {
-	p, err := cfg.SchemaForTime(chunk.From)
+	p, err := cfg.SchemaForTime(chunk.From, chunk.UserID)
+	if err == nil && p.ExternalKeyFormat != "" {
+		if f, ok := externalKeyFormats[p.ExternalKeyFormat]; ok {
+			return f.Format(chunk)
+		}
+	}
+
 	v, _ := p.VersionAsInt()
 	if err == nil && v >= 12 {
-		return cfg.newerExternalKey(chunk)
+		return newerExternalKey(chunk)
 	} else if chunk.ChecksumSet {
-		return cfg.newExternalKey(chunk)
+		return newExternalKey(chunk)
 	} else {
-		return cfg.legacyExternalKey(chunk)
+		return legacyExternalKey(chunk)
 	}
 }
 
 // VersionForChunk will return the schema version associated with the `From` timestamp of a chunk.
 // The schema and chunk must be valid+compatible as the errors are not checked.
 func (cfg SchemaConfig) VersionForChunk(c Chunk) int {
-	p, _ := cfg.SchemaForTime(c.From)
+	p, _ := cfg.SchemaForTime(c.From, c.UserID)
 	v, _ := p.VersionAsInt()
 	return v
 }
 
 // pre-checksum
-func (cfg SchemaConfig) legacyExternalKey(chunk Chunk) string {
+func legacyExternalKey(chunk Chunk) string {
 	// This is the inverse of chunk.parseLegacyExternalKey, with "<user id>/" prepended.
 	// Legacy chunks had the user ID prefix on s3/memcache, but not in DynamoDB.
 	return fmt.Sprintf("%d:%d:%d", (chunk.Fingerprint), int64(chunk.From), int64(chunk.Through))
 }
 
 // post-checksum
-func (cfg SchemaConfig) newExternalKey(chunk Chunk) string {
+func newExternalKey(chunk Chunk) string {
 	// This is the inverse of chunk.parseNewExternalKey.
 	return fmt.Sprintf("%s/%x:%x:%x:%x", chunk.UserID, chunk.Fingerprint, int64(chunk.From), int64(chunk.Through), chunk.Checksum)
 }
 
 // v12+
-func (cfg SchemaConfig) newerExternalKey(chunk Chunk) string {
+func newerExternalKey(chunk Chunk) string {
 	return fmt.Sprintf("%s/%x/%x:%x:%x", chunk.UserID, chunk.Fingerprint, int64(chunk.From), int64(chunk.Through), chunk.Checksum)
 }
+
+// errKeyWrongFormat means the key doesn't have the shape a parser expects, so another
+// parser (or another schema version) might still be able to make sense of it.
+// errKeyCorrupt means the key has the right shape but one of its fields isn't valid.
+var (
+	errKeyWrongFormat = errors.New("chunk key is not in this format")
+	errKeyCorrupt     = errors.New("corrupt chunk key")
+)
+
+// ParseExternalKey is the inverse of SchemaConfig.ExternalKey: given a key as produced by
+// legacyExternalKey, newExternalKey or newerExternalKey, it recovers the Chunk encoded in it.
+// It figures out the schema version from the decoded chunk's From time via SchemaForTime,
+// then delegates to ParseExternalKeyForSchema to confirm the key was actually written in the
+// format that version (or pinned PeriodConfig.ExternalKeyFormat) uses.
+func (cfg SchemaConfig) ParseExternalKey(key string) (Chunk, error) {
+	chunk, err := parseExternalKey(key)
+	if err != nil {
+		return Chunk{}, err
+	}
+
+	p, err := cfg.SchemaForTime(chunk.From, chunk.UserID)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("finding schema for chunk key %q: %w", key, err)
+	}
+	v, err := p.VersionAsInt()
+	if err != nil {
+		return Chunk{}, err
+	}
+
+	if p.ExternalKeyFormat != "" {
+		if f, ok := externalKeyFormats[p.ExternalKeyFormat]; ok {
+			return f.Parse(key)
+		}
+	}
+
+	return cfg.ParseExternalKeyForSchema(v, key)
+}
+
+// ParseExternalKeyForSchema is like ParseExternalKey, but validates the key against an
+// already-known schema version v instead of looking it up via SchemaForTime, and ignores any
+// PeriodConfig.ExternalKeyFormat pin. Callers that already know which PeriodConfig a key
+// belongs to (e.g. while iterating one period's index at a time) can use this to skip the
+// extra lookup.
+//
+// It tries every ExternalKeyFormat registered for v in turn, so a format registered via
+// RegisterExternalKeyFormat is just as usable here as the three built in ones.
+func (cfg SchemaConfig) ParseExternalKeyForSchema(v int, key string) (Chunk, error) {
+	var (
+		tried   int
+		lastErr error
+	)
+	for _, f := range externalKeyFormats {
+		if !f.inSchemaRange(v) {
+			continue
+		}
+		tried++
+
+		chunk, err := f.Parse(key)
+		if err == nil {
+			return chunk, nil
+		}
+		if !errors.Is(err, errKeyWrongFormat) {
+			return Chunk{}, err
+		}
+		lastErr = err
+	}
+
+	if tried == 0 {
+		return Chunk{}, fmt.Errorf("%w: no external key format is registered for schema v%d", errKeyWrongFormat, v)
+	}
+	return Chunk{}, fmt.Errorf("%w: key %q does not match any format registered for schema v%d: %v", errKeyWrongFormat, key, v, lastErr)
+}
+
+// parseKeyAsLegacy parses key as a "legacy" formatted key, failing if it has a userID prefix.
+func parseKeyAsLegacy(key string) (Chunk, error) {
+	if strings.Contains(key, "/") {
+		return Chunk{}, fmt.Errorf("%w: legacy keys have no userID prefix, got %q", errKeyWrongFormat, key)
+	}
+	return parseLegacyExternalKey(key)
+}
+
+// parseKeyAsNew parses key as a "new" (post-checksum, pre-v12) formatted key, failing if it
+// looks like a "newer" (v12+) key instead.
+func parseKeyAsNew(key string) (Chunk, error) {
+	userID, hexKey, ok := splitKeyPrefix(key)
+	if !ok {
+		return Chunk{}, fmt.Errorf("%w: key %q has no userID prefix", errKeyWrongFormat, key)
+	}
+	if strings.Contains(hexKey, "/") {
+		return Chunk{}, fmt.Errorf("%w: key %q looks like a newer (v12+) key", errKeyWrongFormat, key)
+	}
+	return parseNewExternalKey(userID, hexKey)
+}
+
+// parseKeyAsNewer parses key as a "newer" (v12+) formatted key, failing if it looks like a
+// "new" (pre-v12) key instead.
+func parseKeyAsNewer(key string) (Chunk, error) {
+	userID, hexKey, ok := splitKeyPrefix(key)
+	if !ok {
+		return Chunk{}, fmt.Errorf("%w: key %q has no userID prefix", errKeyWrongFormat, key)
+	}
+	if !strings.Contains(hexKey, "/") {
+		return Chunk{}, fmt.Errorf("%w: key %q looks like a pre-v12 checksum key", errKeyWrongFormat, key)
+	}
+	return parseNewExternalKey(userID, hexKey)
+}
+
+// splitKeyPrefix splits a "new"/"newer" formatted key on its first '/' into userID and the
+// remaining hex-encoded fingerprint/from/through/checksum.
+func splitKeyPrefix(key string) (userID, rest string, ok bool) {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// parseExternalKey detects which of the three ExternalKey formats key is in - legacy keys have
+// no userID prefix, newer (v12+) keys have the fingerprint and from:through:checksum separated
+// by a second '/' - and decodes it.
+func parseExternalKey(key string) (Chunk, error) {
+	userID, hexKey, ok := splitKeyPrefix(key)
+	if !ok {
+		return parseLegacyExternalKey(key)
+	}
+	return parseNewExternalKey(userID, hexKey)
+}
+
+// inverse of legacyExternalKey: "<fp>:<from>:<through>", all base-10, no userID in the key.
+func parseLegacyExternalKey(key string) (Chunk, error) {
+	parts := strings.Split(key, ":")
+	if len(parts) != 3 {
+		return Chunk{}, fmt.Errorf("%w: legacy key must have 3 ':'-separated parts, got %q", errKeyWrongFormat, key)
+	}
+
+	fingerprint, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("%w: parsing fingerprint %q: %v", errKeyCorrupt, parts[0], err)
+	}
+	from, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("%w: parsing from %q: %v", errKeyCorrupt, parts[1], err)
+	}
+	through, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("%w: parsing through %q: %v", errKeyCorrupt, parts[2], err)
+	}
+
+	return Chunk{
+		Fingerprint: model.Fingerprint(fingerprint),
+		From:        model.Time(from),
+		Through:     model.Time(through),
+	}, nil
+}
+
+// inverse of newExternalKey/newerExternalKey: hexKey is either "<fp>:<from>:<through>:<checksum>"
+// (v9-v11) or "<fp>/<from>:<through>:<checksum>" (v12+), all hex.
+func parseNewExternalKey(userID, hexKey string) (Chunk, error) {
+	sep := strings.Index(hexKey, "/")
+	if sep < 0 {
+		sep = strings.Index(hexKey, ":")
+	}
+	if sep < 0 {
+		return Chunk{}, fmt.Errorf("%w: key %q is missing fingerprint/from/through/checksum", errKeyWrongFormat, hexKey)
+	}
+	fingerprintPart, rest := hexKey[:sep], hexKey[sep+1:]
+
+	fingerprint, err := strconv.ParseUint(fingerprintPart, 16, 64)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("%w: parsing fingerprint %q: %v", errKeyCorrupt, fingerprintPart, err)
+	}
+
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 {
+		return Chunk{}, fmt.Errorf("%w: expected from:through:checksum, got %q", errKeyWrongFormat, rest)
+	}
+
+	from, err := strconv.ParseInt(parts[0], 16, 64)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("%w: parsing from %q: %v", errKeyCorrupt, parts[0], err)
+	}
+	through, err := strconv.ParseInt(parts[1], 16, 64)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("%w: parsing through %q: %v", errKeyCorrupt, parts[1], err)
+	}
+	checksum, err := strconv.ParseUint(parts[2], 16, 32)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("%w: parsing checksum %q: %v", errKeyCorrupt, parts[2], err)
+	}
+
+	return Chunk{
+		UserID:      userID,
+		Fingerprint: model.Fingerprint(fingerprint),
+		From:        model.Time(from),
+		Through:     model.Time(through),
+		Checksum:    uint32(checksum),
+		ChecksumSet: true,
+	}, nil
+}
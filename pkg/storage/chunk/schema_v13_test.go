@@ -0,0 +1,100 @@
+package chunk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeriodConfig_V13_CreateSchema(t *testing.T) {
+	cfg := PeriodConfig{
+		From:        DayTime{model.TimeFromUnix(0)},
+		Schema:      v13,
+		RowShards:   16,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+	}
+
+	schema, err := cfg.CreateSchema("")
+	require.NoError(t, err)
+	require.NotNil(t, schema)
+}
+
+func TestPeriodConfig_V13_CreateSchema_RejectsInvalidBucketPeriod(t *testing.T) {
+	cfg := PeriodConfig{
+		From:         DayTime{model.TimeFromUnix(0)},
+		Schema:       v13,
+		RowShards:    16,
+		IndexTables:  PeriodicTableConfig{Prefix: "index_"},
+		BucketPeriod: model.Duration(3 * time.Hour), // not one of validBucketPeriods
+	}
+
+	_, err := cfg.CreateSchema("")
+	require.Error(t, err)
+}
+
+func TestPeriodConfig_V13_CreateSchema_RejectsTablePeriodNotMultipleOfBucketPeriod(t *testing.T) {
+	cfg := PeriodConfig{
+		From:         DayTime{model.TimeFromUnix(0)},
+		Schema:       v13,
+		RowShards:    16,
+		IndexTables:  PeriodicTableConfig{Prefix: "index_", Period: 10 * time.Hour},
+		BucketPeriod: model.Duration(6 * time.Hour),
+	}
+
+	_, err := cfg.CreateSchema("")
+	require.ErrorIs(t, err, errInvalidTablePeriod)
+}
+
+func TestPeriodConfig_V13_CreateSchema_AllowsTablePeriodMultipleOfBucketPeriod(t *testing.T) {
+	cfg := PeriodConfig{
+		From:         DayTime{model.TimeFromUnix(0)},
+		Schema:       v13,
+		RowShards:    16,
+		IndexTables:  PeriodicTableConfig{Prefix: "index_", Period: 12 * time.Hour},
+		BucketPeriod: model.Duration(6 * time.Hour),
+	}
+
+	_, err := cfg.CreateSchema("")
+	require.NoError(t, err)
+}
+
+// TestPeriodConfig_V13_BucketsFn_DoesNotCollideWithDailyBuckets checks that bucketsFn's
+// "%s:p<period in hours>:<bucket index>" hash keys can't collide with dailyBuckets'
+// "%s:d<day>" keys, even at the default 24h bucket period where the two would otherwise cover
+// the exact same span.
+func TestPeriodConfig_V13_BucketsFn_DoesNotCollideWithDailyBuckets(t *testing.T) {
+	cfg := PeriodConfig{
+		From:        DayTime{model.TimeFromUnix(0)},
+		Schema:      v13,
+		RowShards:   16,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+	}
+
+	from, through := model.TimeFromUnix(0), model.TimeFromUnix(3600)
+	v13Buckets := cfg.bucketsFn(defaultBucketPeriod)(from, through, "fake")
+	dailyBuckets := cfg.dailyBuckets(from, through, "fake")
+
+	require.Len(t, v13Buckets, 1)
+	require.Len(t, dailyBuckets, 1)
+	require.NotEqual(t, dailyBuckets[0].hashKey, v13Buckets[0].hashKey)
+	require.Equal(t, "fake:d0", dailyBuckets[0].hashKey)
+	require.Equal(t, "fake:p24:0", v13Buckets[0].hashKey)
+}
+
+func TestPeriodConfig_V13_BucketsFn_RowShardsSuffixesHashKey(t *testing.T) {
+	cfg := PeriodConfig{
+		From:        DayTime{model.TimeFromUnix(0)},
+		Schema:      v13,
+		RowShards:   16,
+		IndexTables: PeriodicTableConfig{Prefix: "index_"},
+		Overrides: map[string]PeriodOverride{
+			"team-*": {RowShards: uint32Ptr(4)},
+		},
+	}
+
+	buckets := cfg.bucketsFn(defaultBucketPeriod)(model.TimeFromUnix(0), model.TimeFromUnix(3600), "team-a")
+	require.Len(t, buckets, 1)
+	require.Equal(t, "team-a:p24:0:s4", buckets[0].hashKey)
+}